@@ -0,0 +1,367 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package webhooks analyzes the controller's own ValidatingWebhookConfigurations
+// and MutatingWebhookConfigurations for misconfigurations which would
+// otherwise only surface as admission failures mid-incident - borrowed from
+// the k8sgpt validating / mutating webhook analyzers.
+package webhooks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultSampleInterval is how often Run re-analyzes the webhook
+// configurations in the background when no interval is supplied
+const DefaultSampleInterval = 5 * time.Minute
+
+// caInjectFromAnnotation is the annotation cert-manager's ca-injector reads
+// to decide which Certificate's CA to stamp onto a webhook. When present,
+// the CABundle is intentionally left empty until the injector fills it in,
+// so the CABundle-dependent checks below do not apply
+const caInjectFromAnnotation = "cert-manager.io/inject-ca-from"
+
+// revisionLabel is stamped by the controller on every webhook configuration
+// it manages, and is used to tell those apart from anyone else's
+const revisionLabel = "terraform.appvia.io/rev"
+
+// ExpiryThreshold is how close to expiry a CABundle must be before it is
+// reported as a finding
+const ExpiryThreshold = 30 * 24 * time.Hour
+
+// MaxTimeoutSeconds is the largest webhook timeout which is not reported as
+// a finding
+const MaxTimeoutSeconds = 10
+
+// Severity indicates how urgently a Finding should be addressed
+type Severity string
+
+const (
+	// SeverityWarning indicates a misconfiguration that should be fixed but
+	// is not yet causing admission failures
+	SeverityWarning Severity = "Warning"
+	// SeverityCritical indicates a misconfiguration that will (or already
+	// does) block admission requests
+	SeverityCritical Severity = "Critical"
+)
+
+// Finding is a single problem detected on one of the controller's webhooks
+type Finding struct {
+	// Webhook is the name of the ValidatingWebhookConfiguration or
+	// MutatingWebhookConfiguration
+	Webhook string
+	// Rule is the name of the individual webhook rule within the
+	// configuration
+	Rule string
+	// Reason is a short, stable identifier for the kind of problem found,
+	// suitable for use as a metric label
+	Reason string
+	// Severity is how urgently the finding should be addressed
+	Severity Severity
+	// Message is a human readable description of the problem
+	Message string
+}
+
+// Analyzer inspects the controller's own webhook configurations for
+// problems which would otherwise only surface as admission failures
+type Analyzer struct {
+	// Client is used to read the webhook configurations and their backing
+	// Service endpoints
+	Client client.Client
+	// DialTimeout bounds how long to wait when connecting to a webhook's
+	// backing Service to check its serving certificate. Defaults to five
+	// seconds when zero
+	DialTimeout time.Duration
+
+	cache atomic.Pointer[[]Finding]
+}
+
+// New returns an Analyzer reading webhook configurations via cc
+func New(cc client.Client) *Analyzer {
+	return &Analyzer{Client: cc}
+}
+
+// Run analyzes the webhook configurations once immediately, then repeats on
+// every interval tick until ctx is cancelled, storing the result for
+// Snapshot to serve without blocking on the live checks (which dial every
+// webhook's backing Service). Any error from a single run is logged and
+// the previous snapshot is kept, rather than aborting the loop
+func (a *Analyzer) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+
+	_, _ = a.SampleNow(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = a.SampleNow(ctx)
+		}
+	}
+}
+
+// SampleNow runs the analysis once, immediately, storing the result for
+// Snapshot to serve and returning it to the caller directly. A failed run
+// is logged and leaves the previous snapshot in place rather than clearing it
+func (a *Analyzer) SampleNow(ctx context.Context) ([]Finding, error) {
+	findings, err := Report(ctx, a)
+	if err != nil {
+		log.WithError(err).Error("failed to analyze the webhook configurations")
+
+		return nil, err
+	}
+
+	a.cache.Store(&findings)
+
+	return findings, nil
+}
+
+// Snapshot returns the findings from the most recently completed analysis
+// run, whether started by Run or by SampleNow. It returns nil before any run
+// has completed, and also when the last run found nothing
+func (a *Analyzer) Snapshot() []Finding {
+	findings := a.cache.Load()
+	if findings == nil {
+		return nil
+	}
+
+	return *findings
+}
+
+// Analyze returns every finding across all of the controller's webhook
+// configurations
+func (a *Analyzer) Analyze(ctx context.Context) ([]Finding, error) {
+	var findings []Finding
+
+	validating := &admissionv1.ValidatingWebhookConfigurationList{}
+	if err := a.Client.List(ctx, validating, client.HasLabels{revisionLabel}); err != nil {
+		return nil, fmt.Errorf("failed to list the validating webhook configurations, %w", err)
+	}
+	for i := range validating.Items {
+		wh := &validating.Items[i]
+		caInjected := wh.Annotations[caInjectFromAnnotation] != ""
+		for _, hook := range wh.Webhooks {
+			findings = append(findings, a.analyzeWebhook(ctx, wh.Name, hook.Name, hook.ClientConfig, hook.FailurePolicy, hook.TimeoutSeconds, hook.NamespaceSelector, caInjected)...)
+		}
+	}
+
+	mutating := &admissionv1.MutatingWebhookConfigurationList{}
+	if err := a.Client.List(ctx, mutating, client.HasLabels{revisionLabel}); err != nil {
+		return nil, fmt.Errorf("failed to list the mutating webhook configurations, %w", err)
+	}
+	for i := range mutating.Items {
+		wh := &mutating.Items[i]
+		caInjected := wh.Annotations[caInjectFromAnnotation] != ""
+		for _, hook := range wh.Webhooks {
+			findings = append(findings, a.analyzeWebhook(ctx, wh.Name, hook.Name, hook.ClientConfig, hook.FailurePolicy, hook.TimeoutSeconds, hook.NamespaceSelector, caInjected)...)
+		}
+	}
+
+	return findings, nil
+}
+
+// analyzeWebhook runs every check against a single webhook rule. When
+// caInjected is true, an external injector (e.g. cert-manager) owns the
+// CABundle and the checks which depend on it are skipped, since an empty
+// or not-yet-injected CABundle there is expected, not a misconfiguration
+func (a *Analyzer) analyzeWebhook(
+	ctx context.Context,
+	webhook, rule string,
+	clientConfig admissionv1.WebhookClientConfig,
+	failurePolicy *admissionv1.FailurePolicyType,
+	timeoutSeconds *int32,
+	namespaceSelector *metav1.LabelSelector,
+	caInjected bool,
+) []Finding {
+	var findings []Finding
+
+	if !caInjected {
+		if f := checkCABundleExpiry(webhook, rule, clientConfig.CABundle); f != nil {
+			findings = append(findings, *f)
+		}
+		if f := a.checkCABundleMatchesService(webhook, rule, clientConfig); f != nil {
+			findings = append(findings, *f)
+		}
+	}
+	if f := a.checkReadyBackingPods(ctx, webhook, rule, clientConfig, failurePolicy); f != nil {
+		findings = append(findings, *f)
+	}
+	if f := checkTimeout(webhook, rule, timeoutSeconds); f != nil {
+		findings = append(findings, *f)
+	}
+	if f := checkBroadSelector(webhook, rule, namespaceSelector); f != nil {
+		findings = append(findings, *f)
+	}
+
+	return findings
+}
+
+// checkCABundleExpiry flags a CABundle which has already expired, or will
+// within ExpiryThreshold
+func checkCABundleExpiry(webhook, rule string, caBundle []byte) *Finding {
+	cert, err := parseCertificate(caBundle)
+	if err != nil {
+		return &Finding{
+			Webhook: webhook, Rule: rule, Reason: "CABundleInvalid", Severity: SeverityCritical,
+			Message: fmt.Sprintf("CABundle could not be parsed, %s", err),
+		}
+	}
+
+	switch until := time.Until(cert.NotAfter); {
+	case until <= 0:
+		return &Finding{
+			Webhook: webhook, Rule: rule, Reason: "CABundleExpired", Severity: SeverityCritical,
+			Message: fmt.Sprintf("CABundle expired %s ago", (-until).Round(time.Hour)),
+		}
+	case until <= ExpiryThreshold:
+		return &Finding{
+			Webhook: webhook, Rule: rule, Reason: "CABundleExpiringSoon", Severity: SeverityWarning,
+			Message: fmt.Sprintf("CABundle expires in %s", until.Round(time.Hour)),
+		}
+	}
+
+	return nil
+}
+
+// checkCABundleMatchesService flags a CABundle which does not validate the
+// certificate actually presented by the webhook's backing Service
+func (a *Analyzer) checkCABundleMatchesService(webhook, rule string, clientConfig admissionv1.WebhookClientConfig) *Finding {
+	if clientConfig.Service == nil {
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(clientConfig.CABundle) {
+		return &Finding{
+			Webhook: webhook, Rule: rule, Reason: "CABundleInvalid", Severity: SeverityCritical,
+			Message: "CABundle contains no usable certificates",
+		}
+	}
+
+	timeout := a.DialTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	addr := fmt.Sprintf("%s.%s.svc:%d", clientConfig.Service.Name, clientConfig.Service.Namespace, derefInt32(clientConfig.Service.Port, 443))
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, &tls.Config{RootCAs: pool, ServerName: clientConfig.Service.Name})
+	if err != nil {
+		return &Finding{
+			Webhook: webhook, Rule: rule, Reason: "CABundleMismatch", Severity: SeverityCritical,
+			Message: fmt.Sprintf("CABundle does not validate the certificate served by %s, %s", addr, err),
+		}
+	}
+	_ = conn.Close()
+
+	return nil
+}
+
+// checkReadyBackingPods flags a failurePolicy=Fail webhook whose backing
+// Service has no ready endpoints, which would block the writes it is meant
+// to gate
+func (a *Analyzer) checkReadyBackingPods(ctx context.Context, webhook, rule string, clientConfig admissionv1.WebhookClientConfig, failurePolicy *admissionv1.FailurePolicyType) *Finding {
+	if failurePolicy == nil || *failurePolicy != admissionv1.Fail || clientConfig.Service == nil {
+		return nil
+	}
+
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := a.Client.List(ctx, slices,
+		client.InNamespace(clientConfig.Service.Namespace),
+		client.MatchingLabels{"kubernetes.io/service-name": clientConfig.Service.Name},
+	); err != nil {
+		return nil
+	}
+
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && *endpoint.Conditions.Ready {
+				return nil
+			}
+		}
+	}
+
+	return &Finding{
+		Webhook: webhook, Rule: rule, Reason: "NoReadyBackingPods", Severity: SeverityCritical,
+		Message: fmt.Sprintf("failurePolicy is Fail but service %s/%s has no ready endpoints, this will block cluster writes",
+			clientConfig.Service.Namespace, clientConfig.Service.Name),
+	}
+}
+
+// checkTimeout flags a webhook timeout greater than MaxTimeoutSeconds
+func checkTimeout(webhook, rule string, timeoutSeconds *int32) *Finding {
+	if timeoutSeconds == nil || *timeoutSeconds <= MaxTimeoutSeconds {
+		return nil
+	}
+
+	return &Finding{
+		Webhook: webhook, Rule: rule, Reason: "TimeoutTooHigh", Severity: SeverityWarning,
+		Message: fmt.Sprintf("timeoutSeconds is %ds, requests slower than that will fail the policy", *timeoutSeconds),
+	}
+}
+
+// systemNamespaces must never be matched by an over-broad webhook selector
+var systemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// checkBroadSelector flags a webhook with no namespaceSelector at all,
+// which will also intercept writes to system namespaces
+func checkBroadSelector(webhook, rule string, selector *metav1.LabelSelector) *Finding {
+	if selector == nil || (len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0) {
+		return &Finding{
+			Webhook: webhook, Rule: rule, Reason: "SelectorTooBroad", Severity: SeverityWarning,
+			Message: fmt.Sprintf("no namespaceSelector is set, this will also match %v", systemNamespaces),
+		}
+	}
+
+	return nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func derefInt32(v *int32, def int32) int32 {
+	if v == nil {
+		return def
+	}
+
+	return *v
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package webhooks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func selfSignedCABundle(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCheckCABundleExpiryInvalid(t *testing.T) {
+	f := checkCABundleExpiry("wh", "rule", []byte("not a certificate"))
+	require.NotNil(t, f)
+	assert.Equal(t, "CABundleInvalid", f.Reason)
+	assert.Equal(t, SeverityCritical, f.Severity)
+}
+
+func TestCheckCABundleExpiryExpired(t *testing.T) {
+	ca := selfSignedCABundle(t, time.Now().Add(-time.Hour))
+
+	f := checkCABundleExpiry("wh", "rule", ca)
+	require.NotNil(t, f)
+	assert.Equal(t, "CABundleExpired", f.Reason)
+	assert.Equal(t, SeverityCritical, f.Severity)
+}
+
+func TestCheckCABundleExpirySoon(t *testing.T) {
+	ca := selfSignedCABundle(t, time.Now().Add(24*time.Hour))
+
+	f := checkCABundleExpiry("wh", "rule", ca)
+	require.NotNil(t, f)
+	assert.Equal(t, "CABundleExpiringSoon", f.Reason)
+	assert.Equal(t, SeverityWarning, f.Severity)
+}
+
+func TestCheckCABundleExpiryHealthy(t *testing.T) {
+	ca := selfSignedCABundle(t, time.Now().Add(365*24*time.Hour))
+
+	assert.Nil(t, checkCABundleExpiry("wh", "rule", ca))
+}
+
+func TestCheckTimeoutTooHigh(t *testing.T) {
+	f := checkTimeout("wh", "rule", ptr.To(int32(30)))
+	require.NotNil(t, f)
+	assert.Equal(t, "TimeoutTooHigh", f.Reason)
+}
+
+func TestCheckTimeoutWithinBounds(t *testing.T) {
+	assert.Nil(t, checkTimeout("wh", "rule", ptr.To(int32(5))))
+	assert.Nil(t, checkTimeout("wh", "rule", nil))
+}
+
+func TestCheckBroadSelectorNilSelector(t *testing.T) {
+	f := checkBroadSelector("wh", "rule", nil)
+	require.NotNil(t, f)
+	assert.Equal(t, "SelectorTooBroad", f.Reason)
+}
+
+func TestCheckBroadSelectorEmptySelector(t *testing.T) {
+	f := checkBroadSelector("wh", "rule", &metav1.LabelSelector{})
+	require.NotNil(t, f)
+	assert.Equal(t, "SelectorTooBroad", f.Reason)
+}
+
+func TestCheckBroadSelectorScoped(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"terraform.appvia.io/managed": "true"}}
+
+	assert.Nil(t, checkBroadSelector("wh", "rule", selector))
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package webhooks
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// findingsGauge is keyed by webhook name, rule name and finding reason, set
+// to 1 while the finding is present and reset on every analysis run, so
+// operators can alert on misconfiguration that would otherwise only surface
+// as admission failures mid-incident
+var findingsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "terranetes_webhook_analyzer_finding",
+	Help: "Indicates a detected misconfiguration on one of the controller's webhooks (1 = present)",
+}, []string{"webhook", "rule", "reason", "severity"})
+
+func init() {
+	prometheus.MustRegister(findingsGauge)
+}
+
+// Report logs every finding and updates the Prometheus gauges - intended to
+// be called once on startup and on every subsequent periodic run
+func Report(ctx context.Context, a *Analyzer) ([]Finding, error) {
+	findings, err := a.Analyze(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	findingsGauge.Reset()
+
+	for _, f := range findings {
+		findingsGauge.WithLabelValues(f.Webhook, f.Rule, f.Reason, string(f.Severity)).Set(1)
+
+		log.WithFields(log.Fields{
+			"webhook":  f.Webhook,
+			"rule":     f.Rule,
+			"reason":   f.Reason,
+			"severity": f.Severity,
+		}).Warn(f.Message)
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// WebhookCAInjectorCertManager is the WebhookCAInjector mode which
+	// delegates CABundle population to cert-manager's ca-injector
+	WebhookCAInjectorCertManager = "cert-manager"
+
+	// annotationCAInjectFrom is read by cert-manager's ca-injector to
+	// decide which Certificate's CA should be stamped onto a webhook
+	annotationCAInjectFrom = "cert-manager.io/inject-ca-from"
+)
+
+// useCAInjector returns true when the controller has been configured to
+// delegate CABundle management to an external injector, rather than
+// stamping it itself
+func (s *Server) useCAInjector() bool {
+	return s.config.WebhookCAInjector == WebhookCAInjectorCertManager
+}
+
+// annotateCAInjector annotates o with the cert-manager inject-ca-from
+// annotation and ensures no CABundle is set, so it does not fight with the
+// injector for ownership of the field
+func (s *Server) annotateCAInjector(o client.Object, clientConfigs []*admissionv1.WebhookClientConfig) {
+	annotateCAInjector(o, clientConfigs, s.config.CertManagerCertificate)
+}
+
+// annotateCAInjector sets the cert-manager inject-ca-from annotation (to
+// certificate) on o and clears the CABundle on every clientConfig, so it
+// does not fight with the injector for ownership of the field. Pulled out
+// of the Server method above so it can be exercised without a live Server
+func annotateCAInjector(o client.Object, clientConfigs []*admissionv1.WebhookClientConfig, certificate string) {
+	annotations := o.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[annotationCAInjectFrom] = certificate
+	o.SetAnnotations(annotations)
+
+	for _, cc := range clientConfigs {
+		cc.CABundle = nil
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+)
+
+func TestAnnotateCAInjectorSetsAnnotationAndClearsCABundle(t *testing.T) {
+	wh := newValidatingWebhook([]byte("-----BEGIN CERTIFICATE-----\nMA==\n-----END CERTIFICATE-----\n"))
+	clientConfigs := []*admissionv1.WebhookClientConfig{&wh.Webhooks[0].ClientConfig}
+
+	annotateCAInjector(wh, clientConfigs, "cert-manager/controller-serving-cert")
+
+	assert.Equal(t, "cert-manager/controller-serving-cert", wh.Annotations[annotationCAInjectFrom])
+	assert.Nil(t, wh.Webhooks[0].ClientConfig.CABundle)
+}
+
+func TestAnnotateCAInjectorPreservesExistingAnnotations(t *testing.T) {
+	wh := newValidatingWebhook(nil)
+	wh.Annotations = map[string]string{"other": "value"}
+
+	annotateCAInjector(wh, nil, "cert-manager/controller-serving-cert")
+
+	require.Contains(t, wh.Annotations, "other")
+	assert.Equal(t, "cert-manager/controller-serving-cert", wh.Annotations[annotationCAInjectFrom])
+}
+
+func TestWebhooksSemanticallyEqualIgnoresCABundleWhenInjected(t *testing.T) {
+	existing := newValidatingWebhook([]byte("-----BEGIN CERTIFICATE-----\nMA==\n-----END CERTIFICATE-----\n"))
+	desired := newValidatingWebhook([]byte("-----BEGIN CERTIFICATE-----\nMQ==\n-----END CERTIFICATE-----\n"))
+
+	equal, err := webhooksSemanticallyEqual(existing, desired, true)
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	// ignoreCABundle must not mutate the caller's objects either
+	assert.NotEmpty(t, existing.Webhooks[0].ClientConfig.CABundle)
+	assert.NotEmpty(t, desired.Webhooks[0].ClientConfig.CABundle)
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+// Config is the configuration for the http/webhook server
+type Config struct {
+	// EnableWebhookPrefix indicates we should prefix the webhook names with the
+	// controller name
+	EnableWebhookPrefix bool
+	// EnableNamespaceProtection indicates we should register the namespace
+	// deletion protection webhook
+	EnableNamespaceProtection bool
+	// TLSAuthority is the path to the certificate authority used to verify the
+	// webhook serving certificate
+	TLSAuthority string
+	// TLSCert is the path to the certificate used by the https listener
+	TLSCert string
+	// TLSKey is the path to the private key used by the https listener
+	TLSKey string
+	// TLSAutoGen indicates the server should generate and rotate its own
+	// certificate authority and serving certificate, rather than expecting
+	// them to already exist on disk
+	TLSAutoGen bool
+	// TLSSecretName is the name of the secret, in KUBE_NAMESPACE, used to
+	// persist and share the generated certificate material across replicas
+	// when TLSAutoGen is enabled
+	TLSSecretName string
+	// WebhookCAInjector selects who is responsible for populating the
+	// CABundle on the webhook configurations. When empty the controller
+	// stamps it itself; set to WebhookCAInjectorCertManager to instead
+	// annotate the webhooks for cert-manager's ca-injector to handle
+	WebhookCAInjector string
+	// CertManagerCertificate is the "<namespace>/<name>" of the cert-manager
+	// Certificate whose CA should be injected, used to populate the
+	// cert-manager.io/inject-ca-from annotation when WebhookCAInjector is
+	// WebhookCAInjectorCertManager
+	CertManagerCertificate string
+	// WebhookNamespaceSelector is a label selector expression, e.g.
+	// "terraform.appvia.io/managed=true", applied as the NamespaceSelector
+	// on every webhook entry so the controller can be scoped to a subset of
+	// namespaces rather than intercepting admission cluster-wide
+	WebhookNamespaceSelector string
+	// WebhookObjectSelector is a label selector expression applied as the
+	// ObjectSelector on every webhook entry
+	WebhookObjectSelector string
+	// Revision identifies this controller instance for tag-based webhook
+	// rollout, allowing multiple instances to coexist during a canary
+	// upgrade. Defaults to DefaultRevision when unset
+	Revision string
+	// ListenAddress is the address the webhook https listener binds to
+	ListenAddress string
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import "github.com/spf13/pflag"
+
+// AddFlags registers the command line flags which configure the webhook
+// server onto flags, so the caller's root command exposes them
+func (c *Config) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.ListenAddress, "listen-address", c.ListenAddress,
+		"address the webhook https listener binds to")
+	flags.BoolVar(&c.TLSAutoGen, "tls-autogen", c.TLSAutoGen,
+		"generate and rotate the webhook serving certificate ourselves, rather than expecting it on disk")
+	flags.StringVar(&c.TLSSecretName, "tls-secret-name", c.TLSSecretName,
+		"name of the secret used to share generated certificate material across replicas when --tls-autogen is set")
+	flags.StringVar(&c.WebhookCAInjector, "webhook-ca-injector", c.WebhookCAInjector,
+		"delegate CABundle population to an external injector instead of stamping it ourselves, e.g. \"cert-manager\"")
+	flags.StringVar(&c.CertManagerCertificate, "webhook-ca-injector-certificate", c.CertManagerCertificate,
+		"the \"<namespace>/<name>\" of the cert-manager Certificate to request CA injection from, used with --webhook-ca-injector=cert-manager")
+	flags.StringVar(&c.WebhookNamespaceSelector, "webhook-namespace-selector", c.WebhookNamespaceSelector,
+		"label selector, e.g. \"terraform.appvia.io/managed=true\", scoping which namespaces the webhooks intercept")
+	flags.StringVar(&c.WebhookObjectSelector, "webhook-object-selector", c.WebhookObjectSelector,
+		"label selector scoping which objects the webhooks intercept")
+	flags.StringVar(&c.Revision, "revision", c.Revision,
+		"identifies this controller instance for tag-based webhook rollout, allowing multiple instances to coexist during a canary upgrade")
+}
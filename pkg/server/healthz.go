@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/appvia/terranetes-controller/pkg/analyzer/webhooks"
+	"github.com/appvia/terranetes-controller/pkg/schema"
+)
+
+var (
+	// webhookAnalyzerOnce guards constructing the shared analyzer and
+	// starting its background sampling loop - manageWebhooks (and so this
+	// setup) can run more than once over the life of the process, e.g. on
+	// every TLS certificate rotation
+	webhookAnalyzerOnce sync.Once
+	webhookAnalyzer     *webhooks.Analyzer
+	webhookAnalyzerErr  error
+
+	// handlerRegisterOnce guards the one http.HandleFunc call - the net/http
+	// default mux panics if the same pattern is registered twice
+	handlerRegisterOnce sync.Once
+)
+
+// analyzer lazily builds the shared webhook health analyzer and starts its
+// background sampling loop, which keeps the cache webhooksHealthzHandler
+// serves from up to date without blocking on the live checks it performs
+func (s *Server) analyzer(ctx context.Context) (*webhooks.Analyzer, error) {
+	webhookAnalyzerOnce.Do(func() {
+		cc, err := client.New(s.cfg, client.Options{Scheme: schema.GetScheme()})
+		if err != nil {
+			webhookAnalyzerErr = err
+
+			return
+		}
+
+		webhookAnalyzer = webhooks.New(cc)
+
+		go webhookAnalyzer.Run(ctx, webhooks.DefaultSampleInterval)
+	})
+
+	return webhookAnalyzer, webhookAnalyzerErr
+}
+
+// RegisterHealthz mounts the webhook health analyzer on the default mux at
+// /healthz/webhooks, serving whatever the background analysis loop has most
+// recently sampled
+func (s *Server) RegisterHealthz(ctx context.Context) error {
+	a, err := s.analyzer(ctx)
+	if err != nil {
+		return err
+	}
+
+	handlerRegisterOnce.Do(func() {
+		http.HandleFunc("/healthz/webhooks", func(w http.ResponseWriter, r *http.Request) {
+			webhooksHealthzHandler(w, r, a)
+		})
+	})
+
+	return nil
+}
+
+// LogWebhookHealth runs the webhook health analyzer once, immediately, and
+// logs any findings - called on startup so misconfiguration is surfaced
+// right away rather than only as admission failures mid-incident
+func (s *Server) LogWebhookHealth(ctx context.Context) error {
+	a, err := s.analyzer(ctx)
+	if err != nil {
+		return err
+	}
+
+	findings, err := a.SampleNow(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("findings", len(findings)).Info("completed startup webhook health analysis")
+
+	return nil
+}
+
+// webhooksHealthzHandler serves /healthz/webhooks from the analyzer's most
+// recent background sample, so a probe never blocks on the live TLS dials
+// the checks perform - responding 503 when a critical finding is present,
+// so it can also be wired up as a liveness/readiness check if desired
+func webhooksHealthzHandler(w http.ResponseWriter, r *http.Request, a *webhooks.Analyzer) {
+	findings := a.Snapshot()
+
+	status := http.StatusOK
+	for _, f := range findings {
+		if f.Severity == webhooks.SeverityCritical {
+			status = http.StatusServiceUnavailable
+
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(findings)
+}
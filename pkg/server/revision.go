@@ -0,0 +1,235 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RevisionLabel is stamped on every webhook configuration this
+	// controller owns, identifying which --revision produced it - modelled
+	// on Istio's tag-based webhook revisions, so multiple controller
+	// instances can coexist during a canary upgrade
+	RevisionLabel = "terraform.appvia.io/rev"
+
+	// DefaultRevision is used when no --revision flag is supplied
+	DefaultRevision = "default"
+)
+
+// revision returns the configured revision, defaulting to DefaultRevision
+func (s *Server) revision() string {
+	if s.config.Revision == "" {
+		return DefaultRevision
+	}
+
+	return s.config.Revision
+}
+
+// stampRevision labels o with this controller instance's revision and, for
+// any non-default revision, adds a matching NamespaceSelector requirement so
+// that only namespaces which have opted in via the "terraform.appvia.io/rev:
+// <tag>" label are intercepted by that revision's webhooks. DefaultRevision
+// is left unconstrained, since nothing labels namespaces for it and the
+// controller is expected to intercept cluster-wide until a canary rollout
+// is deliberately started with --revision
+func (s *Server) stampRevision(o client.Object) error {
+	labels := o.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[RevisionLabel] = s.revision()
+	o.SetLabels(labels)
+
+	if s.revision() == DefaultRevision {
+		return nil
+	}
+
+	selector := &metav1.LabelSelectorRequirement{
+		Key:      RevisionLabel,
+		Operator: metav1.LabelSelectorOpIn,
+		Values:   []string{s.revision()},
+	}
+
+	switch o := o.(type) {
+	case *admissionv1.ValidatingWebhookConfiguration:
+		for i := range o.Webhooks {
+			o.Webhooks[i].NamespaceSelector = withRequirement(o.Webhooks[i].NamespaceSelector, selector)
+		}
+
+	case *admissionv1.MutatingWebhookConfiguration:
+		for i := range o.Webhooks {
+			o.Webhooks[i].NamespaceSelector = withRequirement(o.Webhooks[i].NamespaceSelector, selector)
+		}
+
+	default:
+		return fmt.Errorf("expected a validating or mutating webhook, got %T", o)
+	}
+
+	return nil
+}
+
+// withRequirement returns selector with requirement appended, allocating a
+// new LabelSelector if selector is nil
+func withRequirement(selector *metav1.LabelSelector, requirement *metav1.LabelSelectorRequirement) *metav1.LabelSelector {
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+	}
+	selector.MatchExpressions = append(selector.MatchExpressions, *requirement)
+
+	return selector
+}
+
+// listByRevision returns every ValidatingWebhookConfiguration and
+// MutatingWebhookConfiguration labelled as belonging to revision. An empty
+// revision returns every webhook configuration carrying the RevisionLabel,
+// regardless of its value. Teardown in manageWebhooks does not use it; it
+// deletes by name, which carries the revision as a prefix
+func listByRevision(ctx context.Context, cc client.Client, revision string) ([]client.Object, error) {
+	var opts []client.ListOption
+	if revision != "" {
+		opts = append(opts, client.MatchingLabels{RevisionLabel: revision})
+	} else {
+		opts = append(opts, client.HasLabels{RevisionLabel})
+	}
+
+	validating := &admissionv1.ValidatingWebhookConfigurationList{}
+	if err := cc.List(ctx, validating, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list the validating webhooks for revision %q, %w", revision, err)
+	}
+
+	mutating := &admissionv1.MutatingWebhookConfigurationList{}
+	if err := cc.List(ctx, mutating, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list the mutating webhooks for revision %q, %w", revision, err)
+	}
+
+	var objects []client.Object
+	for i := range validating.Items {
+		objects = append(objects, &validating.Items[i])
+	}
+	for i := range mutating.Items {
+		objects = append(objects, &mutating.Items[i])
+	}
+
+	return objects, nil
+}
+
+// PromoteTag rewrites the NamespaceSelector of every webhook configuration
+// belonging to targetRevision so it matches namespaces opted into tag, and
+// strips the tag from any other revision's webhooks - moving which
+// controller instance serves traffic for that tag. Each configuration is
+// updated in turn, so the move is not atomic: an error part way through
+// leaves the configurations already updated in their new state
+func PromoteTag(ctx context.Context, cc client.Client, tag, targetRevision string) error {
+	all, err := listByRevision(ctx, cc, "")
+	if err != nil {
+		return err
+	}
+
+	for _, o := range all {
+		revision := o.GetLabels()[RevisionLabel]
+
+		webhooks, err := namespaceSelectors(o)
+		if err != nil {
+			return err
+		}
+
+		for _, selector := range webhooks {
+			removeTagValue(selector, tag)
+			if revision == targetRevision {
+				addTagValue(selector, tag)
+			}
+		}
+
+		if err := cc.Update(ctx, o); err != nil {
+			return fmt.Errorf("failed to promote tag %q to revision %q on %q, %w", tag, targetRevision, o.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// namespaceSelectors returns every NamespaceSelector held by o
+func namespaceSelectors(o client.Object) ([]*metav1.LabelSelector, error) {
+	var selectors []*metav1.LabelSelector
+
+	switch o := o.(type) {
+	case *admissionv1.ValidatingWebhookConfiguration:
+		for i := range o.Webhooks {
+			if o.Webhooks[i].NamespaceSelector == nil {
+				o.Webhooks[i].NamespaceSelector = &metav1.LabelSelector{}
+			}
+			selectors = append(selectors, o.Webhooks[i].NamespaceSelector)
+		}
+
+	case *admissionv1.MutatingWebhookConfiguration:
+		for i := range o.Webhooks {
+			if o.Webhooks[i].NamespaceSelector == nil {
+				o.Webhooks[i].NamespaceSelector = &metav1.LabelSelector{}
+			}
+			selectors = append(selectors, o.Webhooks[i].NamespaceSelector)
+		}
+
+	default:
+		return nil, fmt.Errorf("expected a validating or mutating webhook, got %T", o)
+	}
+
+	return selectors, nil
+}
+
+// removeTagValue strips value from any "In" requirement on the
+// RevisionLabel within selector
+func removeTagValue(selector *metav1.LabelSelector, value string) {
+	for i, req := range selector.MatchExpressions {
+		if req.Key != RevisionLabel || req.Operator != metav1.LabelSelectorOpIn {
+			continue
+		}
+
+		var values []string
+		for _, v := range req.Values {
+			if v != value {
+				values = append(values, v)
+			}
+		}
+		selector.MatchExpressions[i].Values = values
+	}
+}
+
+// addTagValue ensures value is present on an "In" requirement on the
+// RevisionLabel within selector, creating the requirement if needed
+func addTagValue(selector *metav1.LabelSelector, value string) {
+	for i, req := range selector.MatchExpressions {
+		if req.Key != RevisionLabel || req.Operator != metav1.LabelSelectorOpIn {
+			continue
+		}
+		selector.MatchExpressions[i].Values = append(req.Values, value)
+
+		return
+	}
+
+	selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      RevisionLabel,
+		Operator: metav1.LabelSelectorOpIn,
+		Values:   []string{value},
+	})
+}
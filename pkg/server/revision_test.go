@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddTagValueCreatesRequirement(t *testing.T) {
+	selector := &metav1.LabelSelector{}
+
+	addTagValue(selector, "canary")
+
+	assert.Equal(t, []metav1.LabelSelectorRequirement{
+		{Key: RevisionLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{"canary"}},
+	}, selector.MatchExpressions)
+}
+
+func TestAddTagValueAppendsToExistingRequirement(t *testing.T) {
+	selector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: RevisionLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{"stable"}},
+		},
+	}
+
+	addTagValue(selector, "canary")
+
+	assert.Equal(t, []string{"stable", "canary"}, selector.MatchExpressions[0].Values)
+}
+
+func TestRemoveTagValue(t *testing.T) {
+	selector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: RevisionLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{"stable", "canary"}},
+		},
+	}
+
+	removeTagValue(selector, "canary")
+
+	assert.Equal(t, []string{"stable"}, selector.MatchExpressions[0].Values)
+}
+
+func TestRemoveTagValueIgnoresOtherRequirements(t *testing.T) {
+	selector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"canary"}},
+		},
+	}
+
+	removeTagValue(selector, "canary")
+
+	assert.Equal(t, []string{"canary"}, selector.MatchExpressions[0].Values)
+}
+
+// TestPromoteTagTagRoundTrip exercises the same remove-then-add sequence
+// PromoteTag applies to each webhook's selectors, without needing a fake
+// client to stand in for the cluster
+func TestPromoteTagTagRoundTrip(t *testing.T) {
+	selector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: RevisionLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{"stable"}},
+		},
+	}
+
+	removeTagValue(selector, "stable")
+	addTagValue(selector, "stable")
+
+	assert.Equal(t, []string{"stable"}, selector.MatchExpressions[0].Values)
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"fmt"
+	"os"
+
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// namespaceProtectionExclusions are namespaces which must never be caught
+// by the namespace-deletion protection webhook, regardless of the operator
+// configured namespace selector, so that the controller can never lock
+// itself (or the cluster) out
+var namespaceProtectionExclusions = []string{"kube-system"}
+
+// applySelectors stamps the configured NamespaceSelector / ObjectSelector
+// onto every webhook entry held by o, so the controller can be scoped to a
+// subset of tenants rather than intercepting admission cluster-wide
+func (s *Server) applySelectors(o client.Object) error {
+	namespaceSelector, err := parseSelector(s.config.WebhookNamespaceSelector)
+	if err != nil {
+		return fmt.Errorf("failed to parse the webhook namespace selector, %w", err)
+	}
+	objectSelector, err := parseSelector(s.config.WebhookObjectSelector)
+	if err != nil {
+		return fmt.Errorf("failed to parse the webhook object selector, %w", err)
+	}
+
+	switch o := o.(type) {
+	case *admissionv1.ValidatingWebhookConfiguration:
+		for i := range o.Webhooks {
+			o.Webhooks[i].NamespaceSelector = namespaceSelector.DeepCopy()
+			o.Webhooks[i].ObjectSelector = objectSelector.DeepCopy()
+		}
+
+	case *admissionv1.MutatingWebhookConfiguration:
+		for i := range o.Webhooks {
+			o.Webhooks[i].NamespaceSelector = namespaceSelector.DeepCopy()
+			o.Webhooks[i].ObjectSelector = objectSelector.DeepCopy()
+		}
+
+	default:
+		return fmt.Errorf("expected a validating or mutating webhook, got %T", o)
+	}
+
+	return nil
+}
+
+// applyNamespaceProtectionExclusions ensures the namespace-deletion
+// protection webhook can never match the controller's own namespace, or
+// any other hardcoded exclusion, on top of whatever namespace selector the
+// operator has configured
+func applyNamespaceProtectionExclusions(selector *metav1.LabelSelector) *metav1.LabelSelector {
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+	}
+
+	selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      "kubernetes.io/metadata.name",
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   append([]string{os.Getenv("KUBE_NAMESPACE")}, namespaceProtectionExclusions...),
+	})
+
+	return selector
+}
+
+// parseSelector parses a flag value such as "terraform.appvia.io/managed=true"
+// into a LabelSelector, returning nil (match everything) for an empty string
+func parseSelector(raw string) (*metav1.LabelSelector, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	return metav1.ParseToLabelSelector(raw)
+}
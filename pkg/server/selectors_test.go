@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseSelectorEmptyMatchesEverything(t *testing.T) {
+	selector, err := parseSelector("")
+	require.NoError(t, err)
+	assert.Nil(t, selector)
+}
+
+func TestParseSelectorParsesMatchLabels(t *testing.T) {
+	selector, err := parseSelector("terraform.appvia.io/managed=true")
+	require.NoError(t, err)
+	require.NotNil(t, selector)
+	assert.Equal(t, map[string]string{"terraform.appvia.io/managed": "true"}, selector.MatchLabels)
+}
+
+func TestParseSelectorRejectsInvalidSyntax(t *testing.T) {
+	_, err := parseSelector("not a valid selector===")
+	assert.Error(t, err)
+}
+
+func TestApplyNamespaceProtectionExclusionsNilSelector(t *testing.T) {
+	require.NoError(t, os.Setenv("KUBE_NAMESPACE", "terranetes-system"))
+	defer os.Unsetenv("KUBE_NAMESPACE")
+
+	selector := applyNamespaceProtectionExclusions(nil)
+
+	require.Len(t, selector.MatchExpressions, 1)
+	expr := selector.MatchExpressions[0]
+	assert.Equal(t, "kubernetes.io/metadata.name", expr.Key)
+	assert.Equal(t, metav1.LabelSelectorOpNotIn, expr.Operator)
+	assert.Equal(t, []string{"terranetes-system", "kube-system"}, expr.Values)
+}
+
+func TestApplyNamespaceProtectionExclusionsPreservesExistingSelector(t *testing.T) {
+	require.NoError(t, os.Setenv("KUBE_NAMESPACE", "terranetes-system"))
+	defer os.Unsetenv("KUBE_NAMESPACE")
+
+	given := &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+
+	selector := applyNamespaceProtectionExclusions(given)
+
+	assert.Equal(t, map[string]string{"env": "prod"}, selector.MatchLabels)
+	require.Len(t, selector.MatchExpressions, 1)
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/appvia/terranetes-controller/pkg/schema"
+	"github.com/appvia/terranetes-controller/pkg/utils/certs"
+)
+
+// DefaultListenAddress is used when Config.ListenAddress is unset
+const DefaultListenAddress = ":9443"
+
+// readCABundle returns the certificate authority the webhook configurations
+// should be stamped with, either from the path supplied by the operator or,
+// when TLSAutoGen is enabled, from wherever the generated material is
+// persisted (local disk or a shared Secret)
+func (s *Server) readCABundle(ctx context.Context, cc client.Client) ([]byte, error) {
+	if !s.config.TLSAutoGen {
+		return os.ReadFile(s.config.TLSAuthority)
+	}
+
+	var store certs.Store
+	switch {
+	case s.config.TLSSecretName != "":
+		store = &certs.SecretStore{Client: cc, Name: s.config.TLSSecretName, Namespace: os.Getenv("KUBE_NAMESPACE")}
+	default:
+		store = &certs.FileStore{CAFile: s.config.TLSAuthority, CertFile: s.config.TLSCert, KeyFile: s.config.TLSKey}
+	}
+
+	bundle, found, err := store.Load(ctx)
+	switch {
+	case err != nil:
+		return nil, err
+	case !found:
+		return nil, fmt.Errorf("no generated certificate authority found, has the rotator started yet?")
+	}
+
+	return bundle.CACertPEM, nil
+}
+
+// certHolder allows the https listener to pick up a rotated serving
+// certificate without dropping in-flight connections, by resolving the
+// current keypair on every TLS handshake rather than at listener start
+type certHolder struct {
+	current atomic.Pointer[tls.Certificate]
+}
+
+func (h *certHolder) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := h.current.Load()
+	if cert == nil {
+		return nil, fmt.Errorf("no serving certificate has been loaded yet")
+	}
+
+	return cert, nil
+}
+
+func (h *certHolder) set(certPEM, keyPEM []byte) error {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse the serving certificate, %w", err)
+	}
+	h.current.Store(&pair)
+
+	return nil
+}
+
+// manageTLS ensures the https listener has a valid serving certificate,
+// either one supplied by the operator or, when TLSAutoGen is enabled, one
+// generated and rotated automatically. It returns a GetCertificate function
+// suitable for use on an *tls.Config, so that rotations are picked up by the
+// listener without a restart
+func (s *Server) manageTLS(ctx context.Context) (func(*tls.ClientHelloInfo) (*tls.Certificate, error), error) {
+	holder := &certHolder{}
+
+	if !s.config.TLSAutoGen {
+		certPEM, err := os.ReadFile(s.config.TLSCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the tls certificate, %w", err)
+		}
+		keyPEM, err := os.ReadFile(s.config.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the tls private key, %w", err)
+		}
+		if err := holder.set(certPEM, keyPEM); err != nil {
+			return nil, err
+		}
+
+		return holder.GetCertificate, nil
+	}
+
+	cc, err := client.New(s.cfg, client.Options{Scheme: schema.GetScheme()})
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := os.Getenv("KUBE_NAMESPACE")
+	dnsNames := []string{
+		"controller",
+		fmt.Sprintf("controller.%s", namespace),
+		fmt.Sprintf("controller.%s.svc", namespace),
+		fmt.Sprintf("controller.%s.svc.cluster.local", namespace),
+	}
+
+	var store certs.Store
+	switch {
+	case s.config.TLSSecretName != "":
+		store = &certs.SecretStore{Client: cc, Name: s.config.TLSSecretName, Namespace: namespace}
+	default:
+		store = &certs.FileStore{CAFile: s.config.TLSAuthority, CertFile: s.config.TLSCert, KeyFile: s.config.TLSKey}
+	}
+
+	rotator := certs.NewRotator(store, dnsNames, certs.DefaultCAValidity, certs.DefaultLeafValidity,
+		func(ctx context.Context, bundle *certs.Bundle) error {
+			if err := holder.set(bundle.CertPEM, bundle.KeyPEM); err != nil {
+				return err
+			}
+
+			log.Info("reloading the webhook configurations with the rotated certificate")
+
+			return s.manageWebhooks(ctx, true)
+		},
+	)
+
+	// @step: ensure a bundle is loaded (or generated) synchronously, so a
+	// caller never starts serving traffic before holder has a certificate,
+	// or Start could otherwise fail to register the webhooks against a CA
+	// that hasn't actually been persisted yet. Only the periodic rotation
+	// itself is pushed into the background
+	bundle, err := rotator.Ensure(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := rotator.Watch(ctx, bundle); err != nil {
+			log.WithError(err).Error("certificate rotator exited unexpectedly")
+		}
+	}()
+
+	return holder.GetCertificate, nil
+}
+
+// Start brings up the webhook https listener - it prepares the serving
+// certificate (generating and rotating it when TLSAutoGen is enabled),
+// registers the webhook configurations and then serves admission requests
+// until ctx is cancelled. The listener's tls.Config always resolves the
+// serving certificate via GetCertificate, so a rotation picked up by
+// manageTLS takes effect on the very next handshake without a restart or a
+// dropped in-flight connection
+func (s *Server) Start(ctx context.Context) error {
+	getCertificate, err := s.manageTLS(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to prepare the webhook serving certificate, %w", err)
+	}
+
+	// @step: when TLSAutoGen is enabled, manageTLS has already registered the
+	// webhooks once (via the rotator's Ensure, so the CABundle it just wrote
+	// to the store is what gets stamped on them) - only the static, operator
+	// supplied certificate path still needs it done here
+	if !s.config.TLSAutoGen {
+		if err := s.manageWebhooks(ctx, true); err != nil {
+			return fmt.Errorf("failed to register the webhooks, %w", err)
+		}
+	}
+
+	addr := s.config.ListenAddress
+	if addr == "" {
+		addr = DefaultListenAddress
+	}
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		TLSConfig:         &tls.Config{GetCertificate: getCertificate},
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		// @step: drain in-flight admission requests rather than cutting
+		// their connections, since an apiserver retry racing a pod
+		// termination is worse than a brief delay in shutting down
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.WithError(err).Error("failed to gracefully shut down the webhook https listener")
+		}
+	}()
+
+	log.WithField("address", addr).Info("starting the webhook https listener")
+
+	if err := httpServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("webhook https listener exited unexpectedly, %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/appvia/terranetes-controller/pkg/utils/certs"
+)
+
+// leafDER decodes the raw DER bytes out of a PEM encoded certificate, for
+// comparison against a parsed tls.Certificate's Certificate[0]
+func leafDER(t *testing.T, certPEM []byte) []byte {
+	t.Helper()
+
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+
+	return block.Bytes
+}
+
+func TestCertHolderGetCertificateErrorsBeforeFirstSet(t *testing.T) {
+	holder := &certHolder{}
+
+	_, err := holder.GetCertificate(nil)
+	assert.Error(t, err)
+}
+
+// TestCertHolderGetCertificateReflectsLatestRotation is the regression test
+// for the TLS reload path - a generated bundle is loaded, then rotated, and
+// GetCertificate (what the https listener's tls.Config calls on every
+// handshake) must return the new keypair without anything else changing
+func TestCertHolderGetCertificateReflectsLatestRotation(t *testing.T) {
+	holder := &certHolder{}
+
+	first, err := certs.Generate([]string{"controller.kube-system.svc"}, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, holder.set(first.CertPEM, first.KeyPEM))
+
+	cert, err := holder.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, leafDER(t, first.CertPEM), cert.Certificate[0])
+
+	second, err := certs.Generate([]string{"controller.kube-system.svc"}, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, holder.set(second.CertPEM, second.KeyPEM))
+
+	cert, err = holder.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, leafDER(t, second.CertPEM), cert.Certificate[0])
+	assert.NotEqual(t, first.CertPEM, second.CertPEM)
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyWebhook creates o if it does not yet exist, or updates it only when
+// the desired spec is semantically different from what is already in the
+// cluster, or when a label or annotation set on o is missing or different
+// on the existing object - avoiding the resourceVersion churn and audit-log
+// noise of rewriting the webhook configuration on every reconcile
+func (s *Server) applyWebhook(ctx context.Context, cc client.Client, o client.Object) error {
+	existing := o.DeepCopyObject().(client.Object)
+
+	switch err := cc.Get(ctx, client.ObjectKeyFromObject(o), existing); {
+	case apierrors.IsNotFound(err):
+		return cc.Create(ctx, o)
+	case err != nil:
+		return fmt.Errorf("failed to fetch the existing webhook %q, %w", o.GetName(), err)
+	}
+
+	equal, err := webhooksSemanticallyEqual(existing, o, s.useCAInjector())
+	if err != nil {
+		return fmt.Errorf("failed to compare the webhook %q, %w", o.GetName(), err)
+	}
+	for k, v := range o.GetLabels() {
+		if current, found := existing.GetLabels()[k]; !found || current != v {
+			equal = false
+		}
+	}
+	for k, v := range o.GetAnnotations() {
+		if current, found := existing.GetAnnotations()[k]; !found || current != v {
+			equal = false
+		}
+	}
+	if equal {
+		return nil
+	}
+
+	o.SetResourceVersion(existing.GetResourceVersion())
+
+	return cc.Update(ctx, o)
+}
+
+// webhooksSemanticallyEqual compares the parts of two webhook configurations
+// which this controller owns - the Webhooks slice (rules, sideEffects,
+// failurePolicy, matchPolicy, CABundle, service ref). When ignoreCABundle is
+// true (the CA-injector owns the field) the CABundle is excluded from the
+// comparison entirely, otherwise it is compared tolerant of differing PEM
+// encodings of the same certificate. existing and desired are never mutated
+// - the CABundle fields are zeroed on local deep copies before the final
+// DeepEqual, so the caller's objects (which may go on to be passed to
+// cc.Update) are always left exactly as they were passed in
+func webhooksSemanticallyEqual(existing, desired client.Object, ignoreCABundle bool) (bool, error) {
+	existing = existing.DeepCopyObject().(client.Object)
+	desired = desired.DeepCopyObject().(client.Object)
+
+	existingConfigs, err := webhookClientConfigs(existing)
+	if err != nil {
+		return false, err
+	}
+	desiredConfigs, err := webhookClientConfigs(desired)
+	if err != nil {
+		return false, err
+	}
+	if len(existingConfigs) != len(desiredConfigs) {
+		return false, nil
+	}
+
+	for i := range desiredConfigs {
+		switch {
+		case ignoreCABundle:
+			existingConfigs[i].CABundle, desiredConfigs[i].CABundle = nil, nil
+
+		default:
+			same, err := caBundlesEqual(existingConfigs[i].CABundle, desiredConfigs[i].CABundle)
+			if err != nil {
+				return false, err
+			}
+			if !same {
+				return false, nil
+			}
+			existingConfigs[i].CABundle, desiredConfigs[i].CABundle = nil, nil
+		}
+	}
+
+	switch existing := existing.(type) {
+	case *admissionv1.ValidatingWebhookConfiguration:
+		desired := desired.(*admissionv1.ValidatingWebhookConfiguration)
+
+		return equality.Semantic.DeepEqual(existing.Webhooks, desired.Webhooks), nil
+
+	case *admissionv1.MutatingWebhookConfiguration:
+		desired := desired.(*admissionv1.MutatingWebhookConfiguration)
+
+		return equality.Semantic.DeepEqual(existing.Webhooks, desired.Webhooks), nil
+
+	default:
+		return false, fmt.Errorf("expected a validating or mutating webhook, got %T", existing)
+	}
+}
+
+// webhookClientConfigs returns pointers to every WebhookClientConfig held
+// by a ValidatingWebhookConfiguration or MutatingWebhookConfiguration
+func webhookClientConfigs(o client.Object) ([]*admissionv1.WebhookClientConfig, error) {
+	var configs []*admissionv1.WebhookClientConfig
+
+	switch o := o.(type) {
+	case *admissionv1.ValidatingWebhookConfiguration:
+		for i := range o.Webhooks {
+			configs = append(configs, &o.Webhooks[i].ClientConfig)
+		}
+
+	case *admissionv1.MutatingWebhookConfiguration:
+		for i := range o.Webhooks {
+			configs = append(configs, &o.Webhooks[i].ClientConfig)
+		}
+
+	default:
+		return nil, fmt.Errorf("expected a validating or mutating webhook, got %T", o)
+	}
+
+	return configs, nil
+}
+
+// caBundlesEqual compares two PEM encoded certificate bundles by their
+// decoded DER bytes, so that cosmetic differences in the PEM encoding of
+// an otherwise identical certificate do not trigger an update
+func caBundlesEqual(a, b []byte) (bool, error) {
+	aDER, err := derBlocks(a)
+	if err != nil {
+		return false, err
+	}
+	bDER, err := derBlocks(b)
+	if err != nil {
+		return false, err
+	}
+	if len(aDER) != len(bDER) {
+		return false, nil
+	}
+	for i := range aDER {
+		if string(aDER[i]) != string(bDER[i]) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// derBlocks decodes every PEM block in data and returns their raw DER bytes
+func derBlocks(data []byte) ([][]byte, error) {
+	var blocks [][]byte
+
+	for len(data) > 0 {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block.Bytes)
+	}
+
+	return blocks, nil
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newValidatingWebhook(caBundle []byte) *admissionv1.ValidatingWebhookConfiguration {
+	return &admissionv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Webhooks: []admissionv1.ValidatingWebhook{
+			{
+				Name: "test.terraform.appvia.io",
+				ClientConfig: admissionv1.WebhookClientConfig{
+					CABundle: caBundle,
+				},
+			},
+		},
+	}
+}
+
+func TestWebhooksSemanticallyEqualDoesNotMutateInputs(t *testing.T) {
+	ca := []byte("-----BEGIN CERTIFICATE-----\nMA==\n-----END CERTIFICATE-----\n")
+
+	existing := newValidatingWebhook(ca)
+	desired := newValidatingWebhook(ca)
+
+	equal, err := webhooksSemanticallyEqual(existing, desired, false)
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	// the real regression: comparison must never zero out the caller's
+	// objects, since applyWebhook passes the very same "desired" object on
+	// to cc.Update when anything else differs
+	assert.Equal(t, ca, existing.Webhooks[0].ClientConfig.CABundle)
+	assert.Equal(t, ca, desired.Webhooks[0].ClientConfig.CABundle)
+}
+
+func TestWebhooksSemanticallyEqualDetectsOtherFieldChangesWithoutLosingCABundle(t *testing.T) {
+	ca := []byte("-----BEGIN CERTIFICATE-----\nMA==\n-----END CERTIFICATE-----\n")
+
+	existing := newValidatingWebhook(ca)
+	desired := newValidatingWebhook(ca)
+	desired.Webhooks[0].NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+
+	equal, err := webhooksSemanticallyEqual(existing, desired, false)
+	require.NoError(t, err)
+	assert.False(t, equal)
+
+	// desired is what applyWebhook would send to cc.Update - its CABundle
+	// must still be intact
+	assert.Equal(t, ca, desired.Webhooks[0].ClientConfig.CABundle)
+}
+
+func TestCABundlesEqualTolerantOfPEMWhitespace(t *testing.T) {
+	a := []byte("-----BEGIN CERTIFICATE-----\nMA==\n-----END CERTIFICATE-----\n")
+	b := []byte("-----BEGIN CERTIFICATE-----\r\nMA==\r\n-----END CERTIFICATE-----\r\n")
+
+	equal, err := caBundlesEqual(a, b)
+	require.NoError(t, err)
+	assert.True(t, equal)
+}
+
+func TestCABundlesEqualDetectsDifference(t *testing.T) {
+	a := []byte("-----BEGIN CERTIFICATE-----\nMA==\n-----END CERTIFICATE-----\n")
+	b := []byte("-----BEGIN CERTIFICATE-----\nMQ==\n-----END CERTIFICATE-----\n")
+
+	equal, err := caBundlesEqual(a, b)
+	require.NoError(t, err)
+	assert.False(t, equal)
+}
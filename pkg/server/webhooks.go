@@ -31,6 +31,7 @@ import (
 	"github.com/appvia/terranetes-controller/pkg/register"
 	"github.com/appvia/terranetes-controller/pkg/schema"
 	"github.com/appvia/terranetes-controller/pkg/utils"
+	"github.com/appvia/terranetes-controller/pkg/utils/certs"
 	"github.com/appvia/terranetes-controller/pkg/utils/kubernetes"
 	"github.com/appvia/terranetes-controller/pkg/version"
 )
@@ -43,10 +44,12 @@ func (s *Server) manageWebhooks(ctx context.Context, managed bool) error {
 	}
 	log.WithField("managed", managed).Info("attempting to manage the controller webhooks")
 
-	// @step: read the certificate authority
-	ca, err := os.ReadFile(s.config.TLSAuthority)
+	// @step: read the certificate authority, either from disk or, when
+	// TLSAutoGen is enabled, from wherever the generated material was last
+	// persisted to
+	ca, err := s.readCABundle(ctx, cc)
 	if err != nil {
-		return fmt.Errorf("failed to read the certificate authority file, %w", err)
+		return fmt.Errorf("failed to read the certificate authority, %w", err)
 	}
 
 	documents, err := utils.YAMLDocuments(bytes.NewReader(register.MustAsset("webhooks/manifests.yaml")))
@@ -58,6 +61,9 @@ func (s *Server) manageWebhooks(ctx context.Context, managed bool) error {
 	if s.config.EnableWebhookPrefix {
 		webhookNamePrefix = version.Name + "-"
 	}
+	if revision := s.revision(); revision != DefaultRevision {
+		webhookNamePrefix += revision + "-"
+	}
 
 	// @step: register the validating webhooks
 	for _, x := range documents {
@@ -67,6 +73,8 @@ func (s *Server) manageWebhooks(ctx context.Context, managed bool) error {
 		}
 		o.SetName(webhookNamePrefix + o.GetName())
 
+		var clientConfigs []*admissionv1.WebhookClientConfig
+
 		switch o := o.(type) {
 		case *admissionv1.ValidatingWebhookConfiguration:
 			for i := 0; i < len(o.Webhooks); i++ {
@@ -74,6 +82,7 @@ func (s *Server) manageWebhooks(ctx context.Context, managed bool) error {
 				o.Webhooks[i].ClientConfig.Service.Namespace = os.Getenv("KUBE_NAMESPACE")
 				o.Webhooks[i].ClientConfig.Service.Name = "controller"
 				o.Webhooks[i].ClientConfig.Service.Port = ptr.To(int32(443))
+				clientConfigs = append(clientConfigs, &o.Webhooks[i].ClientConfig)
 			}
 
 		case *admissionv1.MutatingWebhookConfiguration:
@@ -82,15 +91,27 @@ func (s *Server) manageWebhooks(ctx context.Context, managed bool) error {
 				o.Webhooks[i].ClientConfig.Service.Namespace = os.Getenv("KUBE_NAMESPACE")
 				o.Webhooks[i].ClientConfig.Service.Name = "controller"
 				o.Webhooks[i].ClientConfig.Service.Port = ptr.To(int32(443))
+				clientConfigs = append(clientConfigs, &o.Webhooks[i].ClientConfig)
 			}
 
 		default:
 			return fmt.Errorf("expected a validating or mutating webhook, got %T", o)
 		}
 
+		if err := s.applySelectors(o); err != nil {
+			return err
+		}
+		if err := s.stampRevision(o); err != nil {
+			return err
+		}
+
+		if s.useCAInjector() {
+			s.annotateCAInjector(o, clientConfigs)
+		}
+
 		switch managed {
 		case true:
-			if err := kubernetes.CreateOrForceUpdate(ctx, cc, o); err != nil {
+			if err := s.applyWebhook(ctx, cc, o); err != nil {
 				return fmt.Errorf("failed to create / update the webhook, %w", err)
 			}
 
@@ -152,11 +173,23 @@ func (s *Server) manageWebhooks(ctx context.Context, managed bool) error {
 		return nil
 	}
 
+	if err := s.applySelectors(wh); err != nil {
+		return err
+	}
+	wh.Webhooks[0].NamespaceSelector = applyNamespaceProtectionExclusions(wh.Webhooks[0].NamespaceSelector)
+	if err := s.stampRevision(wh); err != nil {
+		return err
+	}
+
+	if s.useCAInjector() {
+		s.annotateCAInjector(wh, []*admissionv1.WebhookClientConfig{&wh.Webhooks[0].ClientConfig})
+	}
+
 	// @step: we manage the webhooks, we either need to create, update or delete
 	// the namespace webhook based on the controller configuration
 	switch s.config.EnableNamespaceProtection {
 	case true:
-		if err := kubernetes.CreateOrForceUpdate(ctx, cc, wh); err != nil {
+		if err := s.applyWebhook(ctx, cc, wh); err != nil {
 			return fmt.Errorf("failed to create / update the namespace webhook, %w", err)
 		}
 	default:
@@ -165,5 +198,15 @@ func (s *Server) manageWebhooks(ctx context.Context, managed bool) error {
 		}
 	}
 
+	// @step: surface any webhook misconfiguration - both as a startup log
+	// line and via the /healthz/webhooks endpoint - now that the webhooks
+	// we manage are in their desired state
+	if err := s.RegisterHealthz(ctx); err != nil {
+		log.WithError(err).Error("failed to register the webhook health endpoint")
+	}
+	if err := s.LogWebhookHealth(ctx); err != nil {
+		log.WithError(err).Error("failed to run the startup webhook health analysis")
+	}
+
 	return nil
 }
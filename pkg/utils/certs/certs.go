@@ -0,0 +1,166 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package certs provides a self-signed certificate authority and leaf
+// certificate, suitable for securing the controller's admission webhook
+// listener without requiring an operator to pre-provision material on disk.
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// DefaultCAValidity is the lifetime given to a generated certificate
+	// authority when none is specified
+	DefaultCAValidity = 365 * 24 * time.Hour
+	// DefaultLeafValidity is the lifetime given to a generated leaf
+	// (serving) certificate when none is specified
+	DefaultLeafValidity = 90 * 24 * time.Hour
+	// RenewalThreshold is the fraction of a certificate's lifetime which
+	// must have elapsed before it is considered due for rotation
+	RenewalThreshold = 2.0 / 3.0
+
+	keySize = 2048
+)
+
+// Bundle is a certificate authority and a leaf certificate, signed by that
+// authority, along with their PEM encodings
+type Bundle struct {
+	// CACertPEM is the PEM encoded certificate authority
+	CACertPEM []byte
+	// CAKeyPEM is the PEM encoded certificate authority private key
+	CAKeyPEM []byte
+	// CertPEM is the PEM encoded leaf (serving) certificate
+	CertPEM []byte
+	// KeyPEM is the PEM encoded leaf private key
+	KeyPEM []byte
+	// IssuedAt is the time the leaf certificate was issued
+	IssuedAt time.Time
+	// CANotAfter is the expiry of the certificate authority
+	CANotAfter time.Time
+	// NotAfter is the expiry of the leaf certificate
+	NotAfter time.Time
+}
+
+// RenewAt returns the point in time at which the bundle should be rotated,
+// i.e. once the renewal threshold fraction of the leaf certificate's
+// lifetime has elapsed
+func (b *Bundle) RenewAt() time.Time {
+	lifetime := b.NotAfter.Sub(b.IssuedAt)
+
+	return b.IssuedAt.Add(time.Duration(float64(lifetime) * RenewalThreshold))
+}
+
+// Generate creates a new self-signed certificate authority and a leaf
+// certificate, signed by that authority, valid for the given dns names
+func Generate(dnsNames []string, caValidity, leafValidity time.Duration) (*Bundle, error) {
+	if caValidity <= 0 {
+		caValidity = DefaultCAValidity
+	}
+	if leafValidity <= 0 {
+		leafValidity = DefaultLeafValidity
+	}
+
+	caKey, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the certificate authority key, %w", err)
+	}
+
+	now := time.Now()
+	caNotAfter := now.Add(caValidity)
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "terranetes-controller-ca"},
+		NotBefore:             now,
+		NotAfter:              caNotAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the certificate authority, %w", err)
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the generated certificate authority, %w", err)
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the leaf certificate key, %w", err)
+	}
+
+	leafNotAfter := now.Add(leafValidity)
+	if leafNotAfter.After(caNotAfter) {
+		leafNotAfter = caNotAfter
+	}
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    now,
+		NotAfter:     leafNotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the leaf certificate, %w", err)
+	}
+
+	return &Bundle{
+		CACertPEM:  encodeCertificate(caDER),
+		CAKeyPEM:   encodeKey(caKey),
+		CertPEM:    encodeCertificate(leafDER),
+		KeyPEM:     encodeKey(leafKey),
+		IssuedAt:   now,
+		CANotAfter: caNotAfter,
+		NotAfter:   leafNotAfter,
+	}, nil
+}
+
+func encodeCertificate(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encodeKey(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// parseCertificate decodes a single PEM encoded certificate
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
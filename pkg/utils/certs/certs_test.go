@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package certs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSignsLeafWithCA(t *testing.T) {
+	bundle, err := Generate([]string{"controller.kube-system.svc"}, 0, 0)
+	require.NoError(t, err)
+
+	caCert, err := parseCertificate(bundle.CACertPEM)
+	require.NoError(t, err)
+	assert.True(t, caCert.IsCA)
+
+	leafCert, err := parseCertificate(bundle.CertPEM)
+	require.NoError(t, err)
+	assert.False(t, leafCert.IsCA)
+	assert.Equal(t, []string{"controller.kube-system.svc"}, leafCert.DNSNames)
+
+	assert.NoError(t, leafCert.CheckSignatureFrom(caCert))
+}
+
+func TestGenerateDefaultsValidityWhenNotGiven(t *testing.T) {
+	bundle, err := Generate([]string{"controller.kube-system.svc"}, 0, 0)
+	require.NoError(t, err)
+
+	assert.WithinDuration(t, bundle.IssuedAt.Add(DefaultCAValidity), bundle.CANotAfter, time.Second)
+	assert.WithinDuration(t, bundle.IssuedAt.Add(DefaultLeafValidity), bundle.NotAfter, time.Second)
+}
+
+func TestGenerateClampsLeafValidityToCAValidity(t *testing.T) {
+	bundle, err := Generate([]string{"controller.kube-system.svc"}, time.Hour, 24*time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, bundle.CANotAfter, bundle.NotAfter)
+}
+
+func TestBundleRenewAt(t *testing.T) {
+	issuedAt := time.Now()
+	bundle := &Bundle{
+		IssuedAt: issuedAt,
+		NotAfter: issuedAt.Add(90 * time.Hour),
+	}
+
+	assert.WithinDuration(t, issuedAt.Add(60*time.Hour), bundle.RenewAt(), time.Second)
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package certs
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Store is implemented by anything capable of persisting and retrieving a
+// certificate bundle - e.g. the local filesystem or a Kubernetes secret -
+// so that multiple controller replicas can share the same material
+type Store interface {
+	// Load returns the current bundle, or false if none has been persisted yet
+	Load(ctx context.Context) (*Bundle, bool, error)
+	// Save persists the bundle
+	Save(ctx context.Context, bundle *Bundle) error
+}
+
+// RotateFunc is invoked whenever the rotator has issued (or loaded) a bundle
+// which should be put into effect - i.e. the webhook configurations updated
+// and the https listener reloaded
+type RotateFunc func(ctx context.Context, bundle *Bundle) error
+
+// Rotator is responsible for ensuring a certificate bundle exists, is shared
+// via the configured Store and is re-issued before it expires
+type Rotator struct {
+	store        Store
+	dnsNames     []string
+	caValidity   time.Duration
+	leafValidity time.Duration
+	onRotate     RotateFunc
+}
+
+// NewRotator returns a rotator for the given dns names, backed by store
+func NewRotator(store Store, dnsNames []string, caValidity, leafValidity time.Duration, onRotate RotateFunc) *Rotator {
+	return &Rotator{
+		store:        store,
+		dnsNames:     dnsNames,
+		caValidity:   caValidity,
+		leafValidity: leafValidity,
+		onRotate:     onRotate,
+	}
+}
+
+// Start ensures a valid bundle is in place and then blocks, rotating the
+// material as it approaches expiry, until the context is cancelled
+func (r *Rotator) Start(ctx context.Context) error {
+	bundle, err := r.Ensure(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.Watch(ctx, bundle)
+}
+
+// Watch blocks, rotating bundle (as previously returned by Ensure) as it
+// approaches expiry, until the context is cancelled. Split out from Start so
+// a caller can Ensure an initial bundle synchronously - surfacing any setup
+// error before it starts serving traffic - and only push the periodic
+// rotation itself into the background
+func (r *Rotator) Watch(ctx context.Context, bundle *Bundle) error {
+	for {
+		wait := time.Until(bundle.RenewAt())
+		if wait < 0 {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-time.After(wait):
+			log.Info("rotating the generated webhook certificate")
+
+			var err error
+			bundle, err = Generate(r.dnsNames, r.caValidity, r.leafValidity)
+			if err != nil {
+				return err
+			}
+			if err := r.store.Save(ctx, bundle); err != nil {
+				return err
+			}
+			if err := r.onRotate(ctx, bundle); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Ensure loads an existing, still valid bundle from the store, or generates
+// and persists a new one, invoking onRotate either way
+func (r *Rotator) Ensure(ctx context.Context) (*Bundle, error) {
+	bundle, found, err := r.store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if found && time.Now().Before(bundle.RenewAt()) {
+		if err := r.onRotate(ctx, bundle); err != nil {
+			return nil, err
+		}
+
+		return bundle, nil
+	}
+
+	bundle, err = Generate(r.dnsNames, r.caValidity, r.leafValidity)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.store.Save(ctx, bundle); err != nil {
+		return nil, err
+	}
+	if err := r.onRotate(ctx, bundle); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2022  Appvia Ltd <info@appvia.io>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package certs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FileStore persists a certificate bundle to the local filesystem, at the
+// paths the https listener is configured to read the serving certificate
+// and the webhook configurations are configured to read the authority from
+type FileStore struct {
+	// CAFile is the path the certificate authority is written to
+	CAFile string
+	// CertFile is the path the leaf certificate is written to
+	CertFile string
+	// KeyFile is the path the leaf private key is written to
+	KeyFile string
+}
+
+// Load implements the Store interface
+func (f *FileStore) Load(_ context.Context) (*Bundle, bool, error) {
+	ca, err := os.ReadFile(f.CAFile)
+	switch {
+	case os.IsNotExist(err):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+
+	cert, err := os.ReadFile(f.CertFile)
+	if err != nil {
+		return nil, false, err
+	}
+	key, err := os.ReadFile(f.KeyFile)
+	if err != nil {
+		return nil, false, err
+	}
+
+	bundle, err := decodeBundle(ca, cert, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return bundle, true, nil
+}
+
+// Save implements the Store interface
+func (f *FileStore) Save(_ context.Context, bundle *Bundle) error {
+	for path, data := range map[string][]byte{
+		f.CAFile:   bundle.CACertPEM,
+		f.CertFile: bundle.CertPEM,
+		f.KeyFile:  bundle.KeyPEM,
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create the directory for %q, %w", path, err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write %q, %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// SecretStore persists a certificate bundle to a Kubernetes secret, allowing
+// multiple controller replicas to share the same material rather than each
+// generating their own
+type SecretStore struct {
+	// Client is used to read / write the secret
+	Client client.Client
+	// Name is the name of the secret
+	Name string
+	// Namespace is the namespace of the secret
+	Namespace string
+}
+
+const (
+	secretKeyCACert = "ca.crt"
+	secretKeyCAKey  = "ca.key"
+	secretKeyCert   = "tls.crt"
+	secretKeyKey    = "tls.key"
+)
+
+// Load implements the Store interface
+func (s *SecretStore) Load(ctx context.Context) (*Bundle, bool, error) {
+	secret := &corev1.Secret{}
+
+	err := s.Client.Get(ctx, types.NamespacedName{Name: s.Name, Namespace: s.Namespace}, secret)
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+
+	bundle, err := decodeBundle(secret.Data[secretKeyCACert], secret.Data[secretKeyCert], secret.Data[secretKeyKey])
+	if err != nil {
+		return nil, false, err
+	}
+
+	return bundle, true, nil
+}
+
+// Save implements the Store interface
+func (s *SecretStore) Save(ctx context.Context, bundle *Bundle) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.Name,
+			Namespace: s.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			secretKeyCACert: bundle.CACertPEM,
+			secretKeyCAKey:  bundle.CAKeyPEM,
+			secretKeyCert:   bundle.CertPEM,
+			secretKeyKey:    bundle.KeyPEM,
+		},
+	}
+
+	existing := &corev1.Secret{}
+	err := s.Client.Get(ctx, types.NamespacedName{Name: s.Name, Namespace: s.Namespace}, existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		return s.Client.Create(ctx, secret)
+	case err != nil:
+		return err
+	}
+
+	existing.Data = secret.Data
+
+	return s.Client.Update(ctx, existing)
+}
+
+// decodeBundle reconstructs a Bundle (including its expiry metadata) from
+// raw PEM material read back from a Store
+func decodeBundle(ca, cert, key []byte) (*Bundle, error) {
+	caCert, err := parseCertificate(ca)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the stored certificate authority, %w", err)
+	}
+	leafCert, err := parseCertificate(cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the stored leaf certificate, %w", err)
+	}
+
+	return &Bundle{
+		CACertPEM:  ca,
+		CertPEM:    cert,
+		KeyPEM:     key,
+		IssuedAt:   leafCert.NotBefore,
+		CANotAfter: caCert.NotAfter,
+		NotAfter:   leafCert.NotAfter,
+	}, nil
+}